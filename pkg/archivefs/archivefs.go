@@ -0,0 +1,340 @@
+// Package archivefs lets wls and wfile treat archive files as if they were
+// directories. A "composite path" like foo.zip/subdir/bar.png is split at
+// the first path component that is a real regular file on disk; everything
+// after that is resolved inside the archive.
+package archivefs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNestedArchive is returned when a composite path tries to reach into an
+// archive that itself lives inside another archive (e.g. foo.zip/bar.zip/x).
+// Nested archives are not supported.
+var ErrNestedArchive = errors.New("archivefs: nested archives are not supported")
+
+// archive is implemented by each registered archive format.
+type archive interface {
+	Open(name string) (io.ReadSeeker, int64, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// opener builds an archive from the raw bytes of an archive file.
+type opener func(data []byte) (archive, error)
+
+// registry maps a lowercase extension (without the dot) to the opener that
+// understands it. Register more formats (tar, tgz, 7z, ...) by adding
+// entries here.
+var registry = map[string]opener{
+	"zip": openZip,
+}
+
+// Register adds support for another archive format, keyed by file
+// extension (without the leading dot, case-insensitive).
+func Register(ext string, o opener) {
+	registry[strings.ToLower(ext)] = o
+}
+
+// Open opens the file or archive member at path and returns a seekable
+// reader along with its size. If path does not cross into an archive, or
+// path names the archive itself with no inner path, this is equivalent to
+// os.Open plus a Stat for the size (so callers that just want the archive's
+// own bytes, e.g. to identify its type, get them without reaching inside).
+func Open(path string) (io.ReadSeeker, int64, error) {
+	archivePath, inner, isComposite, err := split(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isComposite || inner == "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, fi.Size(), nil
+	}
+
+	ar, err := openArchive(archivePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ar.Open(inner)
+}
+
+// ReadDir lists the contents of path, which may be a real directory, an
+// archive root, or a directory inside an archive.
+func ReadDir(path string) ([]fs.DirEntry, error) {
+	archivePath, inner, isComposite, err := split(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isComposite {
+		return os.ReadDir(path)
+	}
+
+	ar, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return ar.ReadDir(inner)
+}
+
+// Stat returns file info for path, synthesizing it from the archive header
+// when path points inside an archive. A path naming the archive itself,
+// with no inner path, returns the archive file's own (real) info rather
+// than a synthetic directory, so callers can still tell it's a zip file.
+func Stat(path string) (fs.FileInfo, error) {
+	archivePath, inner, isComposite, err := split(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isComposite || inner == "" {
+		return os.Stat(path)
+	}
+
+	ar, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return ar.Stat(inner)
+}
+
+// split walks path component by component, normalizing both `/` and `\`
+// separators, and stops at the first component that os.Stat reports as a
+// regular (non-directory) file. It walks upward from the full (cleaned)
+// path rather than down from the root, so absolute paths, drive letters and
+// `\\?\`-style prefixes on Windows are preserved untouched; only the
+// components below the discovered file are ever treated as virtual. If that
+// file's extension is a registered archive format, isComposite is true,
+// archivePath is the real on-disk path, and inner is the remaining
+// (forward-slash-joined) path inside the archive. If path never crosses an
+// archive boundary (it's a plain real file or directory, or doesn't exist),
+// isComposite is false and the caller should fall back to plain os calls,
+// which will report the natural error for a missing path.
+func split(path string) (archivePath, inner string, isComposite bool, err error) {
+	cleaned := filepath.Clean(path)
+
+	var innerParts []string
+	cur := cleaned
+	for {
+		fi, statErr := os.Stat(cur)
+		if statErr == nil {
+			if fi.IsDir() {
+				// A real directory, either the full path itself or an
+				// ancestor we had to walk up to (meaning the requested
+				// path below it genuinely doesn't exist).
+				return "", "", false, nil
+			}
+			ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(cur), "."))
+			if _, ok := registry[ext]; !ok {
+				return "", "", false, nil
+			}
+			return cur, strings.Join(innerParts, "/"), true, nil
+		}
+
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			// Reached the filesystem root without finding anything real;
+			// let the caller's normal os call surface the real error.
+			return "", "", false, nil
+		}
+		innerParts = append([]string{filepath.Base(cur)}, innerParts...)
+		cur = parent
+	}
+}
+
+func openArchive(archivePath string) (archive, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(archivePath), "."))
+	o, ok := registry[ext]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: archivePath, Err: errors.New("archivefs: unsupported archive format")}
+	}
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return o(data)
+}
+
+// zipArchive is the archive implementation backed by archive/zip.
+type zipArchive struct {
+	zr *zip.Reader
+}
+
+func openZip(data []byte) (archive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipArchive{zr: zr}, nil
+}
+
+// resolve locates name (a "/"-joined, already-trimmed inner path) within
+// the archive. It returns the matching *zip.File for a file, isDir true if
+// name names a directory (including the implicit root), or ErrNestedArchive
+// if an intermediate path component is itself a registered archive format
+// (e.g. foo.zip/bar.zip/x). An intermediate component that is merely a
+// plain file (not itself an archive) is not nested-archive; it's a
+// not-a-directory error.
+func (z *zipArchive) resolve(name string) (f *zip.File, isDir bool, err error) {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	if name == "" {
+		return nil, true, nil
+	}
+
+	parts := strings.Split(name, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		component := strings.Join(parts[:i+1], "/")
+		if intermediate := z.fileByName(component); intermediate != nil {
+			ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(intermediate.Name), "."))
+			if _, ok := registry[ext]; ok {
+				return nil, false, ErrNestedArchive
+			}
+			return nil, false, &fs.PathError{Op: "open", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+
+	if match := z.fileByName(name); match != nil {
+		return match, false, nil
+	}
+
+	prefix := name + "/"
+	for _, entry := range z.zr.File {
+		if strings.HasPrefix(entry.Name, prefix) {
+			return nil, true, nil
+		}
+	}
+	return nil, false, fs.ErrNotExist
+}
+
+func (z *zipArchive) fileByName(name string) *zip.File {
+	for _, f := range z.zr.File {
+		if strings.TrimSuffix(f.Name, "/") == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (z *zipArchive) Open(name string) (io.ReadSeeker, int64, error) {
+	f, isDir, err := z.resolve(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isDir {
+		return nil, 0, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+func (z *zipArchive) Stat(name string) (fs.FileInfo, error) {
+	f, isDir, err := z.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if isDir {
+		base := name
+		if idx := strings.LastIndex(name, "/"); idx >= 0 {
+			base = name[idx+1:]
+		}
+		return zipDirInfo{name: base}, nil
+	}
+	return zipFileInfo{f}, nil
+}
+
+func (z *zipArchive) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	if name != "" {
+		if _, isDir, err := z.resolve(name); err != nil {
+			return nil, err
+		} else if !isDir {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+
+	prefix := name
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	seen := make(map[string]bool)
+	var out []fs.DirEntry
+	for _, f := range z.zr.File {
+		fname := strings.TrimSuffix(f.Name, "/")
+		if fname == name || !strings.HasPrefix(fname+"/", prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(fname, prefix)
+		if rel == "" {
+			continue
+		}
+		if slash := strings.Index(rel, "/"); slash >= 0 {
+			child := rel[:slash]
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			out = append(out, fs.FileInfoToDirEntry(zipDirInfo{name: child}))
+		} else {
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			out = append(out, fs.FileInfoToDirEntry(zipFileInfo{f}))
+		}
+	}
+	return out, nil
+}
+
+// zipFileInfo adapts a *zip.File to fs.FileInfo so callers like wls's
+// colorName can inspect the inner entry's mode and extension without
+// knowing it came from an archive.
+type zipFileInfo struct {
+	f *zip.File
+}
+
+func (i zipFileInfo) Name() string       { return filepath.Base(i.f.Name) }
+func (i zipFileInfo) Size() int64        { return int64(i.f.UncompressedSize64) }
+func (i zipFileInfo) Mode() os.FileMode  { return i.f.Mode() }
+func (i zipFileInfo) ModTime() time.Time { return i.f.Modified }
+func (i zipFileInfo) IsDir() bool        { return i.f.Mode().IsDir() }
+func (i zipFileInfo) Sys() any           { return i.f }
+
+// zipDirInfo is a synthetic fs.FileInfo for directories implied by member
+// paths in the zip's central directory (zip files don't always carry
+// explicit directory entries).
+type zipDirInfo struct {
+	name string
+}
+
+func (i zipDirInfo) Name() string       { return i.name }
+func (i zipDirInfo) Size() int64        { return 0 }
+func (i zipDirInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (i zipDirInfo) ModTime() time.Time { return time.Time{} }
+func (i zipDirInfo) IsDir() bool        { return true }
+func (i zipDirInfo) Sys() any           { return nil }
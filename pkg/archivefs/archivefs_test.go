@@ -0,0 +1,145 @@
+package archivefs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestZip creates a zip file at path containing the given name->content
+// entries.
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create(%q): %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close: %v", err)
+	}
+}
+
+func TestOpenAndReadDirIntoArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "test.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"root.txt":      "root contents",
+		"sub/hello.txt": "hello from sub",
+	})
+
+	entries, err := ReadDir(zipPath)
+	if err != nil {
+		t.Fatalf("ReadDir(archive root): %v", err)
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	if !names["root.txt"] || !names["sub"] {
+		t.Fatalf("ReadDir(archive root) = %v, want root.txt and sub", names)
+	}
+
+	r, size, err := Open(filepath.Join(zipPath, "sub", "hello.txt"))
+	if err != nil {
+		t.Fatalf("Open(inner file): %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read inner file: %v", err)
+	}
+	if string(data) != "hello from sub" || int64(len(data)) != size {
+		t.Errorf("inner file contents = %q (size %d), want %q (size %d)", data, size, "hello from sub", size)
+	}
+}
+
+func TestStatBareArchivePathReturnsRealFile(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "plain.zip")
+	writeTestZip(t, zipPath, map[string]string{"a.txt": "a"})
+
+	fi, err := Stat(zipPath)
+	if err != nil {
+		t.Fatalf("Stat(bare archive path): %v", err)
+	}
+	if fi.IsDir() {
+		t.Errorf("Stat(bare archive path) reported a directory, want the archive's own file info")
+	}
+}
+
+func TestOpenPastPlainFileIsNotADirectory(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "test.zip")
+	writeTestZip(t, zipPath, map[string]string{
+		"sub/plainfile.txt": "just a regular file",
+	})
+
+	_, _, err := Open(filepath.Join(zipPath, "sub", "plainfile.txt", "more"))
+	if err == nil {
+		t.Fatal("Open past a plain file component: want an error, got nil")
+	}
+	if errors.Is(err, ErrNestedArchive) {
+		t.Fatalf("Open past a plain file component: got ErrNestedArchive, want a not-a-directory/not-exist error: %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open past a plain file component: got %v, want *fs.PathError or fs.ErrNotExist", err)
+	}
+}
+
+func TestOpenPastNestedArchiveIsErrNestedArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "outer.zip")
+
+	var inner bytes.Buffer
+	izw := zip.NewWriter(&inner)
+	iw, err := izw.Create("x.txt")
+	if err != nil {
+		t.Fatalf("inner zip Create: %v", err)
+	}
+	if _, err := iw.Write([]byte("x")); err != nil {
+		t.Fatalf("inner zip write: %v", err)
+	}
+	if err := izw.Close(); err != nil {
+		t.Fatalf("inner zip Close: %v", err)
+	}
+
+	writeTestZip(t, zipPath, map[string]string{})
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("recreate outer zip: %v", err)
+	}
+	ozw := zip.NewWriter(f)
+	ow, err := ozw.Create("bar.zip")
+	if err != nil {
+		t.Fatalf("outer zip Create: %v", err)
+	}
+	if _, err := ow.Write(inner.Bytes()); err != nil {
+		t.Fatalf("outer zip write: %v", err)
+	}
+	if err := ozw.Close(); err != nil {
+		t.Fatalf("outer zip Close: %v", err)
+	}
+	f.Close()
+
+	_, _, err = Open(filepath.Join(zipPath, "bar.zip", "x"))
+	if !errors.Is(err, ErrNestedArchive) {
+		t.Fatalf("Open into a nested archive member = %v, want ErrNestedArchive", err)
+	}
+}
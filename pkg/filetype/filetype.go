@@ -0,0 +1,456 @@
+// Package filetype identifies file types by magic number, falling back to
+// extension and MIME sniffing. It backs wfile's type reporting and wls's
+// color classification, and is transparent to archive members via
+// pkg/archivefs.
+package filetype
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/archivefs"
+)
+
+// headerBytes is how much of a file's start detection reads by default -
+// enough for every magic matcher (the EBML DocType scan is the deepest,
+// at up to 4096 bytes) without buffering arbitrarily large files just to
+// check a signature at offset 0. Formats that need more than the header,
+// like zip's trailing central directory, are read in full separately.
+const headerBytes = 8192
+
+// Category is a coarse grouping used for things like wls's color output.
+type Category string
+
+const (
+	CategoryArchive    Category = "archive"
+	CategoryExecutable Category = "executable"
+	CategoryImage      Category = "image"
+	CategoryVideo      Category = "video"
+	CategoryAudio      Category = "audio"
+	CategoryDocument   Category = "document"
+	CategoryText       Category = "text"
+	CategoryDirectory  Category = "directory"
+	CategoryUnknown    Category = "unknown"
+)
+
+// Source identifies which detection stage produced a Type.
+const (
+	SourceMagic     = "magic"
+	SourceExtension = "extension"
+	SourceMIME      = "mime"
+	SourceSniff     = "sniff"
+)
+
+// Type describes a detected file type.
+type Type struct {
+	Extension   string
+	MIME        string
+	Description string
+	Category    Category
+	Source      string
+}
+
+// extInfo is the static, no-I/O knowledge used for both wfile's friendly
+// descriptions and wls's cheap (extension-only) color classification.
+type extInfo struct {
+	description string
+	mime        string
+	category    Category
+}
+
+var commonExtensions = map[string]extInfo{
+	"txt":  {"Text File", "text/plain", CategoryText},
+	"md":   {"Markdown", "text/markdown", CategoryText},
+	"jpg":  {"JPEG Image", "image/jpeg", CategoryImage},
+	"jpeg": {"JPEG Image", "image/jpeg", CategoryImage},
+	"png":  {"PNG Image", "image/png", CategoryImage},
+	"gif":  {"GIF Image", "image/gif", CategoryImage},
+	"bmp":  {"Bitmap Image", "image/bmp", CategoryImage},
+	"webp": {"WebP Image", "image/webp", CategoryImage},
+	"mp4":  {"MP4 Video", "video/mp4", CategoryVideo},
+	"mov":  {"QuickTime Video", "video/quicktime", CategoryVideo},
+	"avi":  {"AVI Video", "video/x-msvideo", CategoryVideo},
+	"mkv":  {"Matroska Video", "video/x-matroska", CategoryVideo},
+	"webm": {"WebM Video", "video/webm", CategoryVideo},
+	"mp3":  {"MP3 Audio", "audio/mpeg", CategoryAudio},
+	"wav":  {"WAV Audio", "audio/wav", CategoryAudio},
+	"aac":  {"AAC Audio", "audio/aac", CategoryAudio},
+	"ogg":  {"Ogg Audio", "audio/ogg", CategoryAudio},
+	"flac": {"FLAC Audio", "audio/flac", CategoryAudio},
+	"exe":  {"Windows Executable", "application/vnd.microsoft.portable-executable", CategoryExecutable},
+	"dll":  {"Windows DLL", "application/vnd.microsoft.portable-executable", CategoryExecutable},
+	"bat":  {"Batch Script", "application/x-bat", CategoryExecutable},
+	"cmd":  {"Batch Script", "application/x-bat", CategoryExecutable},
+	"com":  {"DOS Executable", "application/x-msdos-program", CategoryExecutable},
+	"ps1":  {"PowerShell Script", "application/x-powershell", CategoryExecutable},
+	"zip":  {"ZIP Archive", "application/zip", CategoryArchive},
+	"tar":  {"TAR Archive", "application/x-tar", CategoryArchive},
+	"gz":   {"Gzip Archive", "application/gzip", CategoryArchive},
+	"tgz":  {"Gzipped TAR Archive", "application/gzip", CategoryArchive},
+	"7z":   {"7-Zip Archive", "application/x-7z-compressed", CategoryArchive},
+	"rar":  {"RAR Archive", "application/vnd.rar", CategoryArchive},
+	"pdf":  {"PDF Document", "application/pdf", CategoryDocument},
+	"docx": {"Word Document", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", CategoryDocument},
+	"xlsx": {"Excel Workbook", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", CategoryDocument},
+	"pptx": {"PowerPoint Presentation", "application/vnd.openxmlformats-officedocument.presentationml.presentation", CategoryDocument},
+}
+
+// CategoryForExtension classifies an extension (with or without the
+// leading dot) without touching the filesystem. It's meant for cheap,
+// high-volume callers like wls's per-entry coloring, which shouldn't open
+// and sniff every file in a directory just to pick a color.
+func CategoryForExtension(ext string) Category {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if ext == "" {
+		return CategoryUnknown
+	}
+	if info, ok := commonExtensions[ext]; ok {
+		return info.category
+	}
+	if m := mime.TypeByExtension("." + ext); m != "" {
+		return categorizeMIME(m)
+	}
+	return CategoryUnknown
+}
+
+// Detect identifies the type of content read from r. It only has access to
+// the bytes themselves, so detection goes magic table -> content sniffing;
+// callers that also have a path should use DetectPath, which additionally
+// consults the extension and MIME maps. Only a bounded header is read
+// unless the content turns out to need the full bytes (currently, zip and
+// the OOXML formats built on it).
+func Detect(r io.Reader) (Type, error) {
+	header, err := readHeader(r, headerBytes)
+	if err != nil {
+		return Type{}, err
+	}
+
+	data := header
+	if needsFullRead(header) {
+		rest, err := io.ReadAll(r)
+		if err != nil {
+			return Type{}, err
+		}
+		data = append(header, rest...)
+	}
+	return detectBytes(data, ""), nil
+}
+
+// DetectPath identifies the type of the file at path, which may be a real
+// path or (transparently, via pkg/archivefs) a path into an archive.
+// Detection order: magic table -> extension map -> mime.TypeByExtension ->
+// content sniffing, returning the first hit. Like Detect, only a bounded
+// header is read unless the content needs the full file (zip/OOXML),
+// so identifying a huge file doesn't mean buffering all of it.
+func DetectPath(path string) (Type, error) {
+	fi, err := archivefs.Stat(path)
+	if err != nil {
+		return Type{}, err
+	}
+	if fi.IsDir() {
+		return Type{Category: CategoryDirectory, Source: SourceExtension}, nil
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+
+	r, _, err := archivefs.Open(path)
+	if err != nil {
+		return Type{}, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	header, err := readHeader(r, headerBytes)
+	if err != nil {
+		return Type{}, err
+	}
+
+	data := header
+	if needsFullRead(header) {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Type{}, err
+		}
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return Type{}, err
+		}
+	}
+
+	if t, ok := matchMagic(data); ok {
+		t.Source = SourceMagic
+		if t.Extension == "" {
+			t.Extension = ext
+		}
+		return t, nil
+	}
+
+	if ext != "" {
+		if info, ok := commonExtensions[ext]; ok {
+			return Type{Extension: ext, Description: info.description, MIME: info.mime, Category: info.category, Source: SourceExtension}, nil
+		}
+		if m := mime.TypeByExtension("." + ext); m != "" {
+			return Type{Extension: ext, MIME: m, Category: categorizeMIME(m), Source: SourceMIME}, nil
+		}
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	ct := http.DetectContentType(head)
+	if ct == "application/octet-stream" && ext == "" {
+		return Type{Category: CategoryUnknown, Source: SourceSniff}, nil
+	}
+	return Type{Extension: ext, MIME: ct, Category: categorizeMIME(ct), Source: SourceSniff}, nil
+}
+
+// detectBytes runs the magic table then falls back to content sniffing; it
+// has no path, so it cannot consult the extension or MIME maps.
+func detectBytes(data []byte, ext string) Type {
+	if t, ok := matchMagic(data); ok {
+		t.Source = SourceMagic
+		if t.Extension == "" {
+			t.Extension = ext
+		}
+		return t
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	ct := http.DetectContentType(head)
+	if ct == "application/octet-stream" && ext == "" {
+		return Type{Category: CategoryUnknown, Source: SourceSniff}
+	}
+	return Type{Extension: ext, MIME: ct, Category: categorizeMIME(ct), Source: SourceSniff}
+}
+
+func categorizeMIME(m string) Category {
+	switch {
+	case strings.HasPrefix(m, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(m, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(m, "audio/"):
+		return CategoryAudio
+	case strings.HasPrefix(m, "text/"):
+		return CategoryText
+	case m == "application/zip", m == "application/x-tar", m == "application/gzip",
+		m == "application/x-7z-compressed", m == "application/vnd.rar",
+		m == "application/x-xz", m == "application/zstd":
+		return CategoryArchive
+	case m == "application/x-executable", m == "application/vnd.microsoft.portable-executable",
+		m == "application/x-elf", m == "application/x-mach-binary":
+		return CategoryExecutable
+	case m == "application/pdf", strings.Contains(m, "officedocument"), strings.HasPrefix(m, "application/vnd.ms-"):
+		return CategoryDocument
+	default:
+		return CategoryUnknown
+	}
+}
+
+// matchMagic runs every registered signature matcher over data, in a fixed
+// order, and returns the first match. Plain zip has no matcher here; only
+// the OOXML formats built on it are recognized by magic.
+func matchMagic(data []byte) (Type, bool) {
+	for _, m := range magicMatchers {
+		if t, ok := m(data); ok {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+var magicMatchers = []func([]byte) (Type, bool){
+	matchSQLite,
+	matchFlac,
+	matchEBML,
+	matchZstd,
+	matchXZ,
+	match7z,
+	matchRar,
+	matchELF,
+	matchMachO,
+	matchPE,
+	matchZipOOXML,
+}
+
+func hasPrefix(data, sig []byte) bool {
+	return len(data) >= len(sig) && bytes.Equal(data[:len(sig)], sig)
+}
+
+// readHeader reads up to n bytes from r, returning whatever was available
+// if the content is shorter than that.
+func readHeader(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// needsFullRead reports whether a header indicates a format whose
+// detection needs more than the header: zip (and the OOXML formats built
+// on it) requires the whole byte slice, since archive/zip needs random
+// access to the trailing central directory.
+func needsFullRead(header []byte) bool {
+	return hasPrefix(header, []byte{'P', 'K', 0x03, 0x04}) || hasPrefix(header, []byte{'P', 'K', 0x05, 0x06})
+}
+
+func matchSQLite(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte("SQLite format 3\x00")) {
+		return Type{}, false
+	}
+	return Type{Extension: "sqlite", MIME: "application/vnd.sqlite3", Description: "SQLite Database", Category: CategoryDocument}, true
+}
+
+func matchFlac(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte("fLaC")) {
+		return Type{}, false
+	}
+	return Type{Extension: "flac", MIME: "audio/flac", Description: "FLAC Audio", Category: CategoryAudio}, true
+}
+
+// matchEBML covers mkv/webm, which share the EBML container magic and are
+// told apart by the "webm"/"matroska" DocType string near the start of the
+// file.
+func matchEBML(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		return Type{}, false
+	}
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if bytes.Contains(head, []byte("webm")) {
+		return Type{Extension: "webm", MIME: "video/webm", Description: "WebM Video", Category: CategoryVideo}, true
+	}
+	return Type{Extension: "mkv", MIME: "video/x-matroska", Description: "Matroska Video", Category: CategoryVideo}, true
+}
+
+func matchZstd(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{0x28, 0xB5, 0x2F, 0xFD}) {
+		return Type{}, false
+	}
+	return Type{Extension: "zst", MIME: "application/zstd", Description: "Zstandard Archive", Category: CategoryArchive}, true
+}
+
+func matchXZ(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}) {
+		return Type{}, false
+	}
+	return Type{Extension: "xz", MIME: "application/x-xz", Description: "XZ Archive", Category: CategoryArchive}, true
+}
+
+func match7z(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}) {
+		return Type{}, false
+	}
+	return Type{Extension: "7z", MIME: "application/x-7z-compressed", Description: "7-Zip Archive", Category: CategoryArchive}, true
+}
+
+func matchRar(data []byte) (Type, bool) {
+	if hasPrefix(data, []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x01, 0x00}) {
+		return Type{Extension: "rar", MIME: "application/vnd.rar", Description: "RAR Archive (v5)", Category: CategoryArchive}, true
+	}
+	if hasPrefix(data, []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x00}) {
+		return Type{Extension: "rar", MIME: "application/vnd.rar", Description: "RAR Archive (v4)", Category: CategoryArchive}, true
+	}
+	return Type{}, false
+}
+
+func matchELF(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{0x7F, 'E', 'L', 'F'}) {
+		return Type{}, false
+	}
+	bits := "32-bit"
+	if len(data) > 4 && data[4] == 2 {
+		bits = "64-bit"
+	}
+	return Type{Extension: "elf", MIME: "application/x-elf", Description: "ELF Executable (" + bits + ")", Category: CategoryExecutable}, true
+}
+
+func matchMachO(data []byte) (Type, bool) {
+	magics := [][]byte{
+		{0xFE, 0xED, 0xFA, 0xCE}, // 32-bit, big-endian
+		{0xFE, 0xED, 0xFA, 0xCF}, // 64-bit, big-endian
+		{0xCE, 0xFA, 0xED, 0xFE}, // 32-bit, little-endian
+		{0xCF, 0xFA, 0xED, 0xFE}, // 64-bit, little-endian
+		{0xCA, 0xFE, 0xBA, 0xBE}, // universal (fat) binary, big-endian
+		{0xBE, 0xBA, 0xFE, 0xCA}, // universal (fat) binary, little-endian
+	}
+	for _, magic := range magics {
+		if hasPrefix(data, magic) {
+			return Type{Extension: "macho", MIME: "application/x-mach-binary", Description: "Mach-O Binary", Category: CategoryExecutable}, true
+		}
+	}
+	return Type{}, false
+}
+
+// matchPE covers MZ/PE: the DOS header always starts with "MZ", and the
+// PE header's offset lives at 0x3C. When a PE header is present we also
+// read its machine field to tell 32- from 64-bit.
+func matchPE(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{'M', 'Z'}) {
+		return Type{}, false
+	}
+	if len(data) < 0x40 {
+		return Type{Extension: "exe", MIME: "application/vnd.microsoft.portable-executable", Description: "DOS/Windows Executable", Category: CategoryExecutable}, true
+	}
+
+	peOffset := int(binary.LittleEndian.Uint32(data[0x3C:0x40]))
+	if peOffset <= 0 || peOffset+6 > len(data) || !bytes.Equal(data[peOffset:peOffset+4], []byte{'P', 'E', 0, 0}) {
+		return Type{Extension: "exe", MIME: "application/vnd.microsoft.portable-executable", Description: "DOS/Windows Executable", Category: CategoryExecutable}, true
+	}
+
+	bits := "32-bit"
+	switch binary.LittleEndian.Uint16(data[peOffset+4 : peOffset+6]) {
+	case 0x8664, 0xAA64: // IMAGE_FILE_MACHINE_AMD64, IMAGE_FILE_MACHINE_ARM64
+		bits = "64-bit"
+	}
+	return Type{Extension: "exe", MIME: "application/vnd.microsoft.portable-executable", Description: "Windows PE Executable (" + bits + ")", Category: CategoryExecutable}, true
+}
+
+// matchZipOOXML disambiguates Office Open XML documents (docx/xlsx/pptx)
+// from plain zip files by peeking at the archive's member list. A zip that
+// doesn't carry "[Content_Types].xml" is left for the later stages: the
+// extension map when a path is known, otherwise the generic zip MIME type
+// from http.DetectContentType.
+func matchZipOOXML(data []byte) (Type, bool) {
+	if !hasPrefix(data, []byte{'P', 'K', 0x03, 0x04}) && !hasPrefix(data, []byte{'P', 'K', 0x05, 0x06}) {
+		return Type{}, false
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Type{}, false
+	}
+
+	names := make(map[string]bool, len(zr.File))
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	if !names["[Content_Types].xml"] {
+		return Type{}, false
+	}
+
+	switch {
+	case names["word/document.xml"]:
+		return Type{Extension: "docx", MIME: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Description: "Word Document", Category: CategoryDocument}, true
+	case names["xl/workbook.xml"]:
+		return Type{Extension: "xlsx", MIME: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Description: "Excel Workbook", Category: CategoryDocument}, true
+	case names["ppt/presentation.xml"]:
+		return Type{Extension: "pptx", MIME: "application/vnd.openxmlformats-officedocument.presentationml.presentation", Description: "PowerPoint Presentation", Category: CategoryDocument}, true
+	}
+	return Type{}, false
+}
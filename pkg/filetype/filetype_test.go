@@ -0,0 +1,128 @@
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCategoryForExtension(t *testing.T) {
+	cases := []struct {
+		ext  string
+		want Category
+	}{
+		{"exe", CategoryExecutable},
+		{".exe", CategoryExecutable},
+		{"ZIP", CategoryArchive},
+		{"png", CategoryImage},
+		{"mp3", CategoryAudio},
+		{"pdf", CategoryDocument},
+		{"", CategoryUnknown},
+		{"not-a-real-extension", CategoryUnknown},
+	}
+	for _, c := range cases {
+		if got := CategoryForExtension(c.ext); got != c.want {
+			t.Errorf("CategoryForExtension(%q) = %q, want %q", c.ext, got, c.want)
+		}
+	}
+}
+
+func TestDetectMagic(t *testing.T) {
+	cases := []struct {
+		name         string
+		data         []byte
+		wantCategory Category
+		wantExt      string
+	}{
+		{"sqlite", []byte("SQLite format 3\x00rest of header"), CategoryDocument, "sqlite"},
+		{"flac", []byte("fLaC" + "rest"), CategoryAudio, "flac"},
+		{"zstd", []byte{0x28, 0xB5, 0x2F, 0xFD, 0, 0, 0}, CategoryArchive, "zst"},
+		{"xz", []byte{0xFD, '7', 'z', 'X', 'Z', 0x00, 0}, CategoryArchive, "xz"},
+		{"7z", []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C, 0}, CategoryArchive, "7z"},
+		{"rar4", []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x00}, CategoryArchive, "rar"},
+		{"rar5", []byte{0x52, 0x61, 0x72, 0x21, 0x1A, 0x07, 0x01, 0x00}, CategoryArchive, "rar"},
+		{"elf64", append([]byte{0x7F, 'E', 'L', 'F', 2}, make([]byte, 10)...), CategoryExecutable, "elf"},
+		{"macho", []byte{0xCF, 0xFA, 0xED, 0xFE, 0, 0, 0, 0}, CategoryExecutable, "macho"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			typ, err := Detect(bytes.NewReader(c.data))
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if typ.Category != c.wantCategory {
+				t.Errorf("Category = %q, want %q", typ.Category, c.wantCategory)
+			}
+			if typ.Extension != c.wantExt {
+				t.Errorf("Extension = %q, want %q", typ.Extension, c.wantExt)
+			}
+			if typ.Source != SourceMagic {
+				t.Errorf("Source = %q, want %q", typ.Source, SourceMagic)
+			}
+		})
+	}
+}
+
+func TestDetectEBMLDisambiguation(t *testing.T) {
+	ebmlHeader := []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+	webm, err := Detect(bytes.NewReader(append(append([]byte{}, ebmlHeader...), []byte("...webm...")...)))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if webm.Extension != "webm" || webm.Category != CategoryVideo {
+		t.Errorf("webm-flavored EBML got %+v", webm)
+	}
+
+	mkv, err := Detect(bytes.NewReader(append(append([]byte{}, ebmlHeader...), []byte("...matroska...")...)))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if mkv.Extension != "mkv" || mkv.Category != CategoryVideo {
+		t.Errorf("non-webm EBML got %+v", mkv)
+	}
+}
+
+func TestDetectFallsBackToSniff(t *testing.T) {
+	typ, err := Detect(bytes.NewReader([]byte("<!DOCTYPE html><html></html>")))
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if typ.Source != SourceSniff {
+		t.Errorf("Source = %q, want %q", typ.Source, SourceSniff)
+	}
+}
+
+// countingReader tracks how many bytes have been read from it, so a test
+// can assert detection stopped at the bounded header instead of draining
+// the whole thing.
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	return n, err
+}
+
+// TestDetectReadsBoundedHeader guards against re-buffering whole files just
+// to check a few leading bytes: a non-zip payload far larger than
+// headerBytes should only ever have its header consumed.
+func TestDetectReadsBoundedHeader(t *testing.T) {
+	data := append([]byte("fLaC"), make([]byte, 10*headerBytes)...)
+	cr := &countingReader{r: bytes.NewReader(data)}
+
+	typ, err := Detect(cr)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if typ.Extension != "flac" {
+		t.Errorf("Extension = %q, want %q", typ.Extension, "flac")
+	}
+	if cr.read > headerBytes {
+		t.Errorf("Detect read %d bytes, want at most headerBytes (%d)", cr.read, headerBytes)
+	}
+}
@@ -0,0 +1,99 @@
+// Package safepath guards against user-supplied paths that bypass normal
+// Windows path parsing: root local device paths (`\\?\`, `\\.\`, `\??\`)
+// that reach arbitrary devices instead of the filesystem, and reserved DOS
+// device names (CON, NUL, COM1, ...) that open a device even when given a
+// file extension.
+package safepath
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrDevicePath is returned when a path targets a root local device
+// namespace (`\\?\`, `\\.\`, `\??\`) and device paths were not explicitly
+// allowed.
+var ErrDevicePath = errors.New("safepath: path targets a root local device namespace")
+
+// ErrReservedName is returned when a path element names a reserved DOS
+// device (CON, PRN, AUX, NUL, COM1-9, LPT1-9), regardless of extension,
+// trailing dots/spaces, or superscript-digit smuggling (COM¹ etc.).
+var ErrReservedName = errors.New("safepath: path contains a reserved DOS device name")
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// superscriptDigits maps the superscript ¹²³ glyphs (used to smuggle
+// COM¹/COM²/COM³ past naive string checks) back to their ASCII digits.
+var superscriptDigits = map[rune]rune{
+	'¹': '1',
+	'²': '2',
+	'³': '3',
+}
+
+// Check validates a user-supplied path argument. Unless allowDevicePaths is
+// true, it rejects paths whose cleaned form begins with a root local device
+// prefix (`\\?\`, `\\.\`, `\??\`) or contains a bare `?`/`??` element, both
+// of which are ways to reach the NT object namespace instead of a normal
+// filesystem path. Independently of allowDevicePaths, it always rejects
+// paths containing a reserved DOS device name.
+func Check(path string, allowDevicePaths bool) error {
+	cleaned := strings.ReplaceAll(filepath.Clean(path), "/", `\`)
+
+	if !allowDevicePaths && hasDevicePrefix(cleaned) {
+		return ErrDevicePath
+	}
+
+	for _, elem := range strings.Split(cleaned, `\`) {
+		if elem == "" {
+			continue
+		}
+		if elem == "?" || elem == "??" {
+			if allowDevicePaths {
+				continue
+			}
+			return ErrDevicePath
+		}
+		if isReservedElement(elem) {
+			return ErrReservedName
+		}
+	}
+	return nil
+}
+
+// hasDevicePrefix reports whether p begins with a root local device
+// namespace prefix.
+func hasDevicePrefix(p string) bool {
+	return strings.HasPrefix(p, `\\?\`) || strings.HasPrefix(p, `\\.\`) || strings.HasPrefix(p, `\??\`)
+}
+
+// isReservedElement reports whether a single path element names a reserved
+// DOS device, after stripping the tricks Windows itself ignores: trailing
+// dots and spaces, any extension, and superscript-digit smuggling.
+func isReservedElement(elem string) bool {
+	base := normalizeSuperscripts(elem)
+	base = strings.TrimRight(base, " .")
+	if idx := strings.IndexByte(base, '.'); idx >= 0 {
+		base = base[:idx]
+	}
+	base = strings.TrimRight(base, " ")
+	return reservedNames[strings.ToUpper(base)]
+}
+
+func normalizeSuperscripts(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if ascii, ok := superscriptDigits[r]; ok {
+			r = ascii
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package safepath
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name             string
+		path             string
+		allowDevicePaths bool
+		wantErr          error
+	}{
+		{"plain file", `C:\Users\me\file.txt`, false, nil},
+		{"plain relative", `sub\dir\file.txt`, false, nil},
+		{"device prefix blocked", `\\?\C:\Windows\System32`, false, ErrDevicePath},
+		{"device prefix allowed", `\\?\C:\Windows\System32`, true, nil},
+		{"dot device prefix blocked", `\\.\PhysicalDrive0`, false, ErrDevicePath},
+		{"nt object prefix blocked", `\??\C:\Windows`, false, ErrDevicePath},
+		{"bare question mark element", `C:\?\file.txt`, false, ErrDevicePath},
+		{"bare question mark allowed", `C:\?\file.txt`, true, nil},
+		{"reserved name bare", `CON`, false, ErrReservedName},
+		{"reserved name with extension", `NUL.txt`, false, ErrReservedName},
+		{"reserved name case-insensitive", `com1.log`, false, ErrReservedName},
+		{"reserved name trailing dots/spaces", `LPT1. . `, false, ErrReservedName},
+		{"reserved name still blocked when device paths allowed", `CON`, true, ErrReservedName},
+		{"superscript-smuggled reserved name", `COM¹`, false, ErrReservedName},
+		{"not a reserved prefix", `CONSOLE.txt`, false, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Check(c.path, c.allowDevicePaths)
+			if err != c.wantErr {
+				t.Errorf("Check(%q, %v) = %v, want %v", c.path, c.allowDevicePaths, err, c.wantErr)
+			}
+		})
+	}
+}
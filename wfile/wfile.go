@@ -1,78 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io"
-	"mime"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
-)
-
-var common = map[string]string{
-	"txt":  "Text File",
-	"md":   "Markdown",
-	"jpg":  "JPEG Image",
-	"jpeg": "JPEG Image",
-	"png":  "PNG Image",
-	"gif":  "GIF Image",
-	"exe":  "Windows Executable",
-	"dll":  "Windows DLL",
-	"zip":  "ZIP Archive",
-	"tar":  "TAR Archive",
-	"gz":   "Gzip Archive",
-	"pdf":  "PDF Document",
-	"docx": "Word Document",
-	"xlsx": "Excel Workbook",
-	"pptx": "PowerPoint Presentation",
-}
-
-/**
- * This function attempts to determine the type of a file by first checking its extension against a common mapping,
- * then using the MIME type detection based on the extension, and finally falling back to content sniffing if necessary.
- *
- * @param path The file path to analyze
- * @return ext The file extension (without dot), typ The detected file type, and err if any error occurs
- */
-func detectType(path string) (ext string, typ string, err error) {
-	fi, err := os.Stat(path)
-	if err != nil {
-		return "", "", err
-	}
-	if fi.IsDir() {
-		return "", "directory", nil
-	}
-
-	ext = strings.ToLower(filepath.Ext(path))
-	ext = strings.TrimPrefix(ext, ".")
-
-	// Try mapping common extensions
-	if ext != "" {
-		if v, ok := common[ext]; ok {
-			return ext, v, nil
-		}
-		if m := mime.TypeByExtension("." + ext); m != "" {
-			return ext, m, nil
-		}
-	}
 
-	// Fallback: sniff content
-	f, err := os.Open(path)
-	if err != nil {
-		return ext, "", err
-	}
-	defer f.Close()
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/filetype"
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/safepath"
+)
 
-	buf := make([]byte, 512)
-	n, _ := io.ReadFull(f, buf)
-	buf = buf[:n]
-	ct := http.DetectContentType(buf)
-	if ct == "application/octet-stream" && ext == "" {
-		// Unknown
-		return ext, "unknown", nil
+// describeType renders a filetype.Type the way wfile has always reported
+// types: the most specific name available, falling back to the MIME type
+// and then the coarse category.
+func describeType(t filetype.Type) string {
+	switch {
+	case t.Description != "":
+		return t.Description
+	case t.MIME != "":
+		return t.MIME
+	case t.Category == filetype.CategoryDirectory:
+		return "directory"
+	case t.Category != "" && t.Category != filetype.CategoryUnknown:
+		return string(t.Category)
+	default:
+		return "unknown"
 	}
-	return ext, ct, nil
 }
 
 /**
@@ -81,26 +33,36 @@ func detectType(path string) (ext string, typ string, err error) {
  * This program takes one or more file paths as command-line arguments and attempts to determine their types.
  *
  * Usage:
- *  wfile <filename1> [filename2 ...]
+ *  wfile [--allow-device-paths] <filename1> [filename2 ...]
  *
  * @author: Lemon
  */
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintln(os.Stderr, "Usage: wfile <filename>")
+	allowDevicePaths := flag.Bool("allow-device-paths", false, "allow root local device paths such as \\\\?\\C:\\... or \\??\\...")
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: wfile [--allow-device-paths] <filename>")
 		os.Exit(2)
 	}
 
-	for i := 1; i < len(os.Args); i++ {
-		path := os.Args[i]
-		ext, typ, err := detectType(path)
+	for _, path := range paths {
+		if err := safepath.Check(path, *allowDevicePaths); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			continue
+		}
+
+		t, err := filetype.DetectPath(path)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
 			continue
 		}
+
+		ext := t.Extension
 		if ext == "" {
 			ext = "(none)"
 		}
-		fmt.Printf("%s: Extension: %s, File Type: %s\n", path, ext, typ)
+		fmt.Printf("%s: Extension: %s, File Type: %s\n", path, ext, describeType(t))
 	}
 }
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// indexEntry is the subset of a git index entry that status comparison
+// needs: the blob it's tracked against and the stat data for the fast
+// unmodified check.
+type indexEntry struct {
+	mode      uint32
+	sha1      [20]byte
+	size      uint32
+	mtimeSec  uint32
+	mtimeNsec uint32
+}
+
+// gitStatusIndex bundles everything -g needs to classify one directory's
+// entries: the parsed index of the enclosing repo and its root .gitignore
+// rules.
+type gitStatusIndex struct {
+	repoRoot string
+	entries  map[string]indexEntry
+	ignore   []ignoreRule
+}
+
+// loadGitStatus walks upward from dir to find the enclosing Git repository
+// (following a `gitdir:` pointer for worktrees), parses its index, and
+// loads the root .gitignore. It never shells out to git.
+func loadGitStatus(dir string) (*gitStatusIndex, error) {
+	gitDir, repoRoot, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseIndex(filepath.Join(gitDir, "index"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitStatusIndex{
+		repoRoot: repoRoot,
+		entries:  entries,
+		ignore:   loadGitignore(repoRoot),
+	}, nil
+}
+
+// StatusFor returns the two-character porcelain-style status for the entry
+// at path. Since this only ever compares the working tree against the
+// index (never against HEAD), the first column - which in real `git
+// status` reflects staged-vs-HEAD state - is always blank; only "??" and
+// "!!" use both characters.
+func (g *gitStatusIndex) StatusFor(path string, fi os.FileInfo) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "  "
+	}
+	rel, err := filepath.Rel(g.repoRoot, abs)
+	if err != nil {
+		return "  "
+	}
+	rel = filepath.ToSlash(rel)
+
+	if fi.IsDir() {
+		return g.statusForDir(rel)
+	}
+
+	entry, tracked := g.entries[rel]
+	if !tracked {
+		if isIgnored(g.ignore, rel, false) {
+			return "!!"
+		}
+		return "??"
+	}
+
+	if uint32(fi.Size()) == entry.size && uint32(fi.ModTime().Unix()) == entry.mtimeSec {
+		return "  "
+	}
+
+	sum, err := blobSHA1(path)
+	if err != nil || sum != entry.sha1 {
+		return " M"
+	}
+	return "  "
+}
+
+// statusForDir classifies a directory entry. The index only ever stores
+// blob (file) paths, never directories, so a plain g.entries[rel] lookup
+// would always miss and wrongly report every ordinary tracked directory as
+// untracked. Instead, treat rel as a tracked subtree if any index entry
+// falls under it, and aggregate: " M" if any child is missing or its
+// content hash differs from the index (children whose size/mtime differ
+// are re-hashed before being counted as modified), blank otherwise.
+// Only a subtree with no tracked entries at all is classified as
+// untracked/ignored.
+func (g *gitStatusIndex) statusForDir(rel string) string {
+	prefix := rel + "/"
+	if rel == "." {
+		prefix = ""
+	}
+
+	tracked := false
+	modified := false
+	for name, entry := range g.entries {
+		if rel != "." && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		tracked = true
+
+		full := filepath.Join(g.repoRoot, name)
+		fi, err := os.Stat(full)
+		if err != nil || uint32(fi.Size()) != entry.size || uint32(fi.ModTime().Unix()) != entry.mtimeSec {
+			sum, err := blobSHA1(full)
+			if err != nil || sum != entry.sha1 {
+				modified = true
+				break
+			}
+		}
+	}
+
+	if !tracked {
+		if isIgnored(g.ignore, rel, true) {
+			return "!!"
+		}
+		return "??"
+	}
+	if modified {
+		return " M"
+	}
+	return "  "
+}
+
+// findGitDir walks upward from dir looking for a .git directory or,
+// for worktrees, a .git file containing a `gitdir:` pointer. It returns
+// the real git directory (where HEAD/index/objects live) and the working
+// tree root it was found under.
+func findGitDir(dir string) (gitDir, workTree string, err error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		candidate := filepath.Join(abs, ".git")
+		info, statErr := os.Stat(candidate)
+		if statErr == nil {
+			if info.IsDir() {
+				return candidate, abs, nil
+			}
+
+			data, err := os.ReadFile(candidate)
+			if err != nil {
+				return "", "", err
+			}
+			line := strings.TrimSpace(string(data))
+			const prefix = "gitdir:"
+			if !strings.HasPrefix(line, prefix) {
+				return "", "", fmt.Errorf("gitstatus: malformed .git file: %s", candidate)
+			}
+			gd := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			if !filepath.IsAbs(gd) {
+				gd = filepath.Join(abs, gd)
+			}
+			return gd, abs, nil
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", "", fmt.Errorf("gitstatus: not a git repository (or any parent up to %s)", dir)
+		}
+		abs = parent
+	}
+}
+
+// parseIndex reads a git index file (versions 2, 3 and 4) directly,
+// returning tracked path -> index entry. See gitformat-index(5): a 12-byte
+// header, then sorted entries of a 40-byte fixed prefix (times, dev, ino,
+// mode, uid, gid, size), a 20-byte sha1 and 2-byte flags, then the
+// (possibly prefix-compressed, in v4) path name.
+func parseIndex(path string) (map[string]indexEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "DIRC" {
+		return nil, fmt.Errorf("gitstatus: not a git index file: %s", path)
+	}
+
+	version := binary.BigEndian.Uint32(data[4:8])
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	entries := make(map[string]indexEntry, count)
+	offset := 12
+	prevName := ""
+
+	for i := uint32(0); i < count; i++ {
+		start := offset
+		if offset+62 > len(data) {
+			return nil, fmt.Errorf("gitstatus: truncated index entry %d", i)
+		}
+
+		mtimeSec := binary.BigEndian.Uint32(data[offset+8 : offset+12])
+		mtimeNsec := binary.BigEndian.Uint32(data[offset+12 : offset+16])
+		mode := binary.BigEndian.Uint32(data[offset+24 : offset+28])
+		size := binary.BigEndian.Uint32(data[offset+36 : offset+40])
+		offset += 40
+
+		var sha1sum [20]byte
+		copy(sha1sum[:], data[offset:offset+20])
+		offset += 20
+
+		flags := binary.BigEndian.Uint16(data[offset : offset+2])
+		offset += 2
+		if version >= 3 && flags&0x4000 != 0 {
+			offset += 2 // extended flags, unused here
+		}
+
+		var name string
+		if version >= 4 {
+			stripLen, n, err := decodeGitVarint(data[offset:])
+			if err != nil {
+				return nil, fmt.Errorf("gitstatus: invalid index name varint at entry %d: %w", i, err)
+			}
+			offset += n
+
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("gitstatus: unterminated index name at entry %d", i)
+			}
+			suffix := string(data[offset : offset+end])
+			offset += end + 1
+
+			keep := len(prevName) - int(stripLen)
+			if keep < 0 {
+				keep = 0
+			}
+			name = prevName[:keep] + suffix
+		} else {
+			nameLen := int(flags & 0x0FFF)
+			if nameLen == 0x0FFF {
+				end := bytes.IndexByte(data[offset:], 0)
+				if end < 0 {
+					return nil, fmt.Errorf("gitstatus: unterminated index name at entry %d", i)
+				}
+				name = string(data[offset : offset+end])
+				offset += end + 1
+			} else {
+				if offset+nameLen > len(data) {
+					return nil, fmt.Errorf("gitstatus: truncated index name at entry %d", i)
+				}
+				name = string(data[offset : offset+nameLen])
+				offset += nameLen + 1 // + NUL terminator
+			}
+
+			// Versions 2/3 pad each entry with NULs to a multiple of 8
+			// bytes, counted from the start of the entry.
+			if pad := (8 - (offset-start)%8) % 8; pad > 0 {
+				offset += pad
+			}
+		}
+
+		prevName = name
+		entries[name] = indexEntry{mode: mode, sha1: sha1sum, size: size, mtimeSec: mtimeSec, mtimeNsec: mtimeNsec}
+	}
+
+	return entries, nil
+}
+
+// decodeGitVarint decodes the MSB-first varint git uses for the v4 index's
+// path-compression prefix length (see varint.c's decode_varint): unlike
+// encoding/binary's LEB128, the accumulator is incremented by one on every
+// continuation byte before the next 7 bits are shifted in, so the two
+// schemes only agree on single-byte values. Returns the decoded value and
+// the number of bytes consumed.
+func decodeGitVarint(data []byte) (val uint64, n int, err error) {
+	for n < len(data) {
+		b := data[n]
+		n++
+		val = (val << 7) | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return val, n, nil
+		}
+		val++
+	}
+	return 0, 0, fmt.Errorf("gitstatus: truncated varint")
+}
+
+// blobSHA1 hashes path the way git hashes a blob object: sha1("blob "
+// + decimal size + NUL + content).
+func blobSHA1(path string) ([20]byte, error) {
+	var sum [20]byte
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sum, err
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// ignoreRule is one parsed line of a .gitignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	anchored bool
+	dirOnly  bool
+}
+
+// loadGitignore reads the repo root's .gitignore, if any. This is
+// intentionally a small subset of git's real ignore semantics (no nested
+// .gitignore files, no "**"): enough to tell untracked-but-ignored files
+// apart from genuinely new ones.
+func loadGitignore(repoRoot string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(repoRoot, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		negate := strings.HasPrefix(trimmed, "!")
+		if negate {
+			trimmed = trimmed[1:]
+		}
+		anchored := strings.HasPrefix(trimmed, "/")
+		trimmed = strings.TrimPrefix(trimmed, "/")
+		dirOnly := strings.HasSuffix(trimmed, "/")
+		trimmed = strings.TrimSuffix(trimmed, "/")
+
+		rules = append(rules, ignoreRule{pattern: trimmed, negate: negate, anchored: anchored, dirOnly: dirOnly})
+	}
+	return rules
+}
+
+// isIgnored applies rules in order (later rules, including negations,
+// override earlier ones) the way .gitignore does.
+func isIgnored(rules []ignoreRule, relPath string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(relPath) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matches does simple fnmatch-style matching: anchored patterns match the
+// full relative path, unanchored ones also match just the base name so
+// e.g. "*.log" matches at any depth.
+func (r ignoreRule) matches(relPath string) bool {
+	if r.anchored {
+		ok, _ := filepath.Match(r.pattern, relPath)
+		return ok
+	}
+	if ok, _ := filepath.Match(r.pattern, relPath); ok {
+		return true
+	}
+	ok, _ := filepath.Match(r.pattern, filepath.Base(relPath))
+	return ok
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit is a small helper for building fixture repos; the product code
+// itself never shells out to git (see parseIndex), only these tests do.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestStatusForFilesAndDirs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "test")
+
+	mustWrite(t, filepath.Join(dir, "tracked.txt"), "unchanged\n")
+	mustWrite(t, filepath.Join(dir, "modme.txt"), "will change\n")
+	if err := os.MkdirAll(filepath.Join(dir, "subdir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(dir, "subdir", "file.txt"), "clean subtree\n")
+	runGit(t, dir, "add", "tracked.txt", "modme.txt", "subdir/file.txt")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+
+	mustWrite(t, filepath.Join(dir, ".gitignore"), "*.log\n")
+	runGit(t, dir, "add", ".gitignore")
+	runGit(t, dir, "commit", "-q", "-m", "ignore")
+
+	mustWrite(t, filepath.Join(dir, "modme.txt"), "will change\nnow changed\n")
+	mustWrite(t, filepath.Join(dir, "untracked.txt"), "new\n")
+	mustWrite(t, filepath.Join(dir, "debug.log"), "log data\n")
+
+	gi, err := loadGitStatus(dir)
+	if err != nil {
+		t.Fatalf("loadGitStatus: %v", err)
+	}
+
+	cases := map[string]string{
+		"tracked.txt":   "  ",
+		"modme.txt":     " M",
+		"untracked.txt": "??",
+		"debug.log":     "!!",
+		"subdir":        "  ", // tracked, fully clean subtree
+	}
+	for name, want := range cases {
+		p := filepath.Join(dir, name)
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if got := gi.StatusFor(p, fi); got != want {
+			t.Errorf("StatusFor(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	// Modify a file inside the tracked subtree; the directory status
+	// should now aggregate to modified instead of silently staying clean.
+	mustWrite(t, filepath.Join(dir, "subdir", "file.txt"), "now dirty\n")
+	fi, err := os.Stat(filepath.Join(dir, "subdir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gi.StatusFor(filepath.Join(dir, "subdir"), fi); got != " M" {
+		t.Errorf("StatusFor(subdir) after editing a child = %q, want \" M\"", got)
+	}
+}
+
+func TestDecodeGitVarint(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  []byte
+		want  uint64
+		wantN int
+	}{
+		{"single byte", []byte{0x05}, 5, 1},
+		{"two bytes, matches git's decode_varint", []byte{0x85, 0x10}, 784, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, n, err := decodeGitVarint(c.data)
+			if err != nil {
+				t.Fatalf("decodeGitVarint(%v): %v", c.data, err)
+			}
+			if got != c.want || n != c.wantN {
+				t.Errorf("decodeGitVarint(%v) = (%d, %d), want (%d, %d)", c.data, got, n, c.want, c.wantN)
+			}
+		})
+	}
+}
+
+// TestParseIndexV4LongStripLength covers the case the review flagged: a
+// real index.version=4 repo with a sorted pair of entries whose common
+// prefix is short but the *stripped suffix of the previous name* is long
+// (>=128 bytes) - exactly what needs a multi-byte strip-length varint,
+// which is where git's MSB-first scheme and encoding/binary's LEB128
+// diverge. The two names here ("a" + 149 x's + ".txt", then "a" + 5 y's +
+// ".txt") are chosen so the correct decode keeps a short, non-empty
+// prefix ("a") of the previous name: a wrong (too-large) decode drives
+// the kept-prefix length negative, which clamps to 0 and silently drops
+// that leading "a", producing "yyyyy.txt" instead of "ayyyyy.txt".
+func TestParseIndexV4LongStripLength(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "test")
+	runGit(t, dir, "config", "index.version", "4")
+
+	first := "a" + strings.Repeat("x", 149) + ".txt"
+	second := "a" + strings.Repeat("y", 5) + ".txt"
+	mustWrite(t, filepath.Join(dir, first), "one\n")
+	mustWrite(t, filepath.Join(dir, second), "two\n")
+	runGit(t, dir, "add", "-A")
+
+	gitDir, _, err := findGitDir(dir)
+	if err != nil {
+		t.Fatalf("findGitDir: %v", err)
+	}
+	entries, err := parseIndex(filepath.Join(gitDir, "index"))
+	if err != nil {
+		t.Fatalf("parseIndex: %v", err)
+	}
+
+	if _, ok := entries[first]; !ok {
+		t.Errorf("parseIndex: missing entry %q; got keys %v", first, keysOf(entries))
+	}
+	if _, ok := entries[second]; !ok {
+		t.Errorf("parseIndex: missing entry %q (decoded wrong, e.g. as %q); got keys %v", second, second[1:], keysOf(entries))
+	}
+}
+
+func keysOf(m map[string]indexEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -5,11 +5,15 @@ import (
 
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/archivefs"
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/filetype"
+	"github.com/Lemon-Juiced/WinCoreUtils/pkg/safepath"
 	"golang.org/x/sys/windows"
 	"golang.org/x/term"
 )
@@ -31,6 +35,11 @@ import (
  *  wls [directory]
  *  wls -a [directory]  # Include hidden files
  *  wls -l [directory]  # Long listing format
+ *  wls -l -h [directory]  # Long listing with human-readable sizes
+ *  wls -l -S [directory]  # Long listing sorted by size, largest first
+ *  wls -l -t [directory]  # Long listing sorted by modification time, newest first
+ *  wls -g [directory]  # Prefix each entry with its git status (2-char code)
+ *  wls --allow-device-paths [directory]  # Permit \\?\... and \??\... paths
  *
  * If no directory is specified, it lists the contents of the current directory.
  *
@@ -39,6 +48,13 @@ import (
 func main() {
 	longFlag := flag.Bool("l", false, "long listing")
 	allFlag := flag.Bool("a", false, "include hidden files")
+	humanFlag := flag.Bool("h", false, "with -l, print sizes in human-readable binary units (KiB, MiB, ...)")
+	sizeSortFlag := flag.Bool("S", false, "sort by file size, largest first")
+	timeSortFlag := flag.Bool("t", false, "sort by modification time, newest first")
+	var gitFlag bool
+	flag.BoolVar(&gitFlag, "g", false, "prefix each entry with its git status, if the directory is in a git working tree")
+	flag.BoolVar(&gitFlag, "git", false, "alias for -g")
+	allowDevicePaths := flag.Bool("allow-device-paths", false, "allow root local device paths such as \\\\?\\C:\\... or \\??\\...")
 	// Expand combined short flags (e.g. -la -> -l -a) so `-la` works like many shells
 	if len(os.Args) > 1 {
 		os.Args = append([]string{os.Args[0]}, expandCombinedFlags(os.Args[1:])...)
@@ -53,12 +69,27 @@ func main() {
 		dir = args[0]
 	}
 
-	entries, err := os.ReadDir(dir)
+	if err := safepath.Check(dir, *allowDevicePaths); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	entries, err := archivefs.ReadDir(dir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 
+	var gitInfo *gitStatusIndex
+	if gitFlag {
+		gi, err := loadGitStatus(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: -g:", err)
+		} else {
+			gitInfo = gi
+		}
+	}
+
 	names := make([]string, 0, len(entries))
 	for _, e := range entries {
 		name := e.Name()
@@ -95,7 +126,37 @@ func main() {
 		}
 	}
 
-	sort.Strings(names)
+	// Stat every entry once up front so sorting by size/mtime and the
+	// column/long layouts don't each re-stat the same path.
+	listing := make([]dirListEntry, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		fi, err := archivefs.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			continue
+		}
+		e := dirListEntry{name: name, path: path, fi: fi}
+		if gitInfo != nil {
+			e.gitStatus = gitInfo.StatusFor(path, fi)
+		}
+		listing = append(listing, e)
+	}
+
+	switch {
+	case *timeSortFlag:
+		sort.SliceStable(listing, func(i, j int) bool {
+			return listing[i].fi.ModTime().After(listing[j].fi.ModTime())
+		})
+	case *sizeSortFlag:
+		sort.SliceStable(listing, func(i, j int) bool {
+			return listing[i].fi.Size() > listing[j].fi.Size()
+		})
+	default:
+		sort.SliceStable(listing, func(i, j int) bool {
+			return listing[i].name < listing[j].name
+		})
+	}
 
 	// Determine terminal width
 	width := 80
@@ -105,25 +166,21 @@ func main() {
 
 	// If long listing requested, print one entry per line with details
 	if *longFlag {
-		for _, name := range names {
-			path := filepath.Join(dir, name)
-			fi, err := os.Stat(path)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "error:", err)
-				continue
+		opts := longOpts{human: *humanFlag}
+		for _, e := range listing {
+			line := formatLong(e.fi, e.path, opts)
+			if gitInfo != nil {
+				line = colorStatus(e.gitStatus) + " " + line
 			}
-			perms := fi.Mode().String()
-			size := fi.Size()
-			mtime := fi.ModTime().Format("Jan _2 15:04")
-			fmt.Printf("%s %8d %s %s\n", perms, size, mtime, colorName(name, path, fi))
+			fmt.Println(line)
 		}
 		return
 	}
 
 	// Layout in columns like unix `ls` (vertical filling)
 	maxLen := 0
-	for _, n := range names {
-		if l := len(n); l > maxLen {
+	for _, e := range listing {
+		if l := len(e.name); l > maxLen {
 			maxLen = l
 		}
 	}
@@ -131,31 +188,40 @@ func main() {
 		return
 	}
 
-	colWidth := maxLen + 2
+	// A git status prefix is always 2 status characters plus a separating
+	// space; its length is constant (and ANSI-colored, like colorName)
+	// so it doesn't throw off the plain-length padding math below.
+	prefixLen := 0
+	if gitInfo != nil {
+		prefixLen = 3
+	}
+
+	colWidth := maxLen + 2 + prefixLen
 	cols := width / colWidth
 	if cols < 1 {
 		cols = 1
 	}
-	rows := (len(names) + cols - 1) / cols
+	rows := (len(listing) + cols - 1) / cols
 
 	for r := 0; r < rows; r++ {
 		for c := 0; c < cols; c++ {
 			idx := c*rows + r
-			if idx >= len(names) {
+			if idx >= len(listing) {
 				continue
 			}
-			name := names[idx]
-			path := filepath.Join(dir, name)
-			fi, _ := os.Stat(path)
+			e := listing[idx]
+			if gitInfo != nil {
+				fmt.Print(colorStatus(e.gitStatus) + " ")
+			}
 			// Pad all but the last printed column. When padding, print colored name then spaces to maintain alignment.
-			if c == cols-1 || c*rows+r+rows >= len(names) {
-				fmt.Print(colorName(name, path, fi))
+			if c == cols-1 || c*rows+r+rows >= len(listing) {
+				fmt.Print(colorName(e.name, e.path, e.fi))
 			} else {
-				padded := colWidth - len(name)
+				padded := colWidth - prefixLen - len(e.name)
 				if padded < 0 {
 					padded = 0
 				}
-				fmt.Print(colorName(name, path, fi))
+				fmt.Print(colorName(e.name, e.path, e.fi))
 				fmt.Print(strings.Repeat(" ", padded))
 			}
 		}
@@ -163,6 +229,15 @@ func main() {
 	}
 }
 
+// dirListEntry pairs a displayed name with its path and pre-fetched stat
+// info, so sorting and rendering never need to stat the same entry twice.
+type dirListEntry struct {
+	name      string
+	path      string
+	fi        os.FileInfo
+	gitStatus string // only populated when -g is set
+}
+
 // expandCombinedFlags turns combined short flags like `-la` into `-l -a`.
 // It leaves long flags (`--foo`) and non-flag arguments unchanged.
 func expandCombinedFlags(args []string) []string {
@@ -207,6 +282,106 @@ func isHidden(path string) bool {
 	return attrs&windows.FILE_ATTRIBUTE_HIDDEN != 0
 }
 
+// longOpts controls how formatLong renders a single long-listing line.
+type longOpts struct {
+	human bool
+}
+
+// formatLong renders the "perms owner/group size mtime name" line used by
+// the -l long-listing mode. It's factored out of main so the size and
+// ownership formatting can be tested without spawning the binary.
+func formatLong(fi os.FileInfo, path string, opts longOpts) string {
+	perms := fi.Mode().String()
+
+	sizeStr := fmt.Sprintf("%8d", fi.Size())
+	if opts.human {
+		sizeStr = fmt.Sprintf("%8s", humanSize(fi.Size()))
+	}
+
+	mtime := fi.ModTime().Format("Jan _2 15:04")
+
+	owner, group := "-", "-"
+	if o, g, err := ownerGroup(path); err == nil {
+		owner, group = o, g
+	}
+
+	return fmt.Sprintf("%s %s:%s %s %s %s", perms, owner, group, sizeStr, mtime, colorName(fi.Name(), path, fi))
+}
+
+// humanSize formats n using binary units (B, KiB, MiB, GiB, TiB), matching
+// the convention of GNU `ls -lh`: no decimal below 1 KiB, one decimal place
+// at and above it.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	units := [...]string{"KiB", "MiB", "GiB", "TiB"}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit && exp < len(units)-1; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	value := float64(n) / float64(div)
+	// Choosing the unit from truncated integer division can leave a value
+	// that rounds up to the next unit at one decimal place (e.g. n one
+	// byte below 1 MiB rounding to "1024.0KiB" instead of rolling over to
+	// "1.0MiB"); bump the unit in that case.
+	if rounded := math.Round(value*10) / 10; rounded >= unit && exp < len(units)-1 {
+		exp++
+		div *= unit
+		value = float64(n) / float64(div)
+	}
+
+	return fmt.Sprintf("%.1f%s", value, units[exp])
+}
+
+// ownerGroup looks up the NTFS owner and primary group of path via the
+// file's security descriptor, returning each as "DOMAIN\Account". If only
+// the group's account lookup fails, group is returned empty with a nil error.
+func ownerGroup(path string) (owner, group string, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	h, err := windows.CreateFile(p, windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", "", err
+	}
+	defer windows.CloseHandle(h)
+
+	sd, err := windows.GetSecurityInfo(h, windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION)
+	if err != nil {
+		return "", "", err
+	}
+
+	ownerSid, _, err := sd.Owner()
+	if err != nil {
+		return "", "", err
+	}
+	groupSid, _, err := sd.Group()
+	if err != nil {
+		return "", "", err
+	}
+
+	ownerAccount, ownerDomain, _, err := ownerSid.LookupAccount("")
+	if err != nil {
+		return "", "", err
+	}
+	groupAccount, groupDomain, _, err := groupSid.LookupAccount("")
+	if err != nil {
+		return ownerDomain + `\` + ownerAccount, "", nil
+	}
+
+	return ownerDomain + `\` + ownerAccount, groupDomain + `\` + groupAccount, nil
+}
+
 /**
  * Returns the name wrapped in ANSI color codes based on file type
  *
@@ -222,78 +397,19 @@ func colorName(name, path string, fi os.FileInfo) string {
 	if fi.IsDir() {
 		return blue(name)
 	}
-	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
-	if isExecutable(ext) {
+	switch filetype.CategoryForExtension(filepath.Ext(name)) {
+	case filetype.CategoryExecutable:
 		return green(name)
-	}
-	if isArchive(ext) {
+	case filetype.CategoryArchive:
 		return red(name)
-	}
-	if isImageOrVideo(ext) {
+	case filetype.CategoryImage, filetype.CategoryVideo:
 		return magenta(name)
-	}
-	if isAudio(ext) {
+	case filetype.CategoryAudio:
 		return cyan(name)
 	}
 	return name
 }
 
-/**
- * Helper functions to determine file types based on extensions for coloring
- *
- * @param ext The file extension (without dot)
- * @return bool indicating if the file is of a certain type
- */
-func isExecutable(ext string) bool {
-	switch ext {
-	case "exe", "bat", "cmd", "com", "ps1":
-		return true
-	}
-	return false
-}
-
-/**
- * Checks if the file extension corresponds to a common archive format
- *
- * @param ext The file extension (without dot)
- * @return bool indicating if the file is an archive
- */
-func isArchive(ext string) bool {
-	switch ext {
-	case "zip", "tar", "gz", "tgz", "7z", "rar":
-		return true
-	}
-	return false
-}
-
-/**
- * Checks if the file extension corresponds to a common image or video format
- *
- * @param ext The file extension (without dot)
- * @return bool indicating if the file is an image or video
- */
-func isImageOrVideo(ext string) bool {
-	switch ext {
-	case "jpg", "jpeg", "png", "gif", "bmp", "webp", "mp4", "mkv", "mov", "avi":
-		return true
-	}
-	return false
-}
-
-/**
- * Checks if the file extension corresponds to a common audio format
- *
- * @param ext The file extension (without dot)
- * @return bool indicating if the file is an audio file
- */
-func isAudio(ext string) bool {
-	switch ext {
-	case "mp3", "wav", "flac", "aac", "ogg":
-		return true
-	}
-	return false
-}
-
 /**
  * Wraps a string in ANSI color codes for the given color code
  *
@@ -310,3 +426,21 @@ func green(s string) string   { return colorWrap(32, s) }
 func red(s string) string     { return colorWrap(31, s) }
 func magenta(s string) string { return colorWrap(35, s) }
 func cyan(s string) string    { return colorWrap(36, s) }
+func gray(s string) string    { return colorWrap(90, s) }
+
+// colorStatus colors a -g status code independently of the filename it's
+// attached to: red for modified, green for staged/added, gray for
+// untracked-but-ignored. Untracked ("??") and unmodified ("  ") entries are
+// left uncolored.
+func colorStatus(code string) string {
+	switch code {
+	case "!!":
+		return gray(code)
+	case " M", "M ", "MM":
+		return red(code)
+	case "A ", "AM":
+		return green(code)
+	default:
+		return code
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanSize(t *testing.T) {
+	cases := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1536, "1.5KiB"},
+		{1024 * 1024, "1.0MiB"},
+		{1024 * 1024 * 1024, "1.0GiB"},
+		{1024 * 1024 * 1024 * 1024, "1.0TiB"},
+		// One byte below each power-of-1024 boundary: truncated integer
+		// division picks the lower unit, but the value rounds to the
+		// unit's own "1024.0" at one decimal place, so it must roll over
+		// to the next unit instead.
+		{1024*1024 - 1, "1.0MiB"},
+		{1024*1024*1024 - 1, "1.0GiB"},
+		{1024*1024*1024*1024 - 1, "1.0TiB"},
+	}
+	for _, c := range cases {
+		if got := humanSize(c.in); got != c.want {
+			t.Errorf("humanSize(%d) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// fakeFileInfo lets formatLong be tested without touching the filesystem.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f fakeFileInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeFileInfo) Sys() any           { return nil }
+
+func TestFormatLong(t *testing.T) {
+	fi := fakeFileInfo{
+		name:    "example.txt",
+		size:    2048,
+		mode:    0o644,
+		modTime: time.Date(2026, time.January, 2, 3, 4, 0, 0, time.UTC),
+	}
+
+	// ownerGroup will fail to resolve a nonexistent path and formatLong
+	// falls back to "-" for owner/group, so the path itself doesn't need
+	// to exist for this test.
+	line := formatLong(fi, "/nonexistent/example.txt", longOpts{human: true})
+	if !strings.Contains(line, "2.0KiB") {
+		t.Errorf("formatLong with human=true: expected human-readable size in %q", line)
+	}
+	if !strings.Contains(line, "example.txt") {
+		t.Errorf("formatLong: expected name in %q", line)
+	}
+
+	line = formatLong(fi, "/nonexistent/example.txt", longOpts{human: false})
+	if !strings.Contains(line, "2048") {
+		t.Errorf("formatLong with human=false: expected raw byte size in %q", line)
+	}
+}